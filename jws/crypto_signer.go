@@ -0,0 +1,172 @@
+package jws
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"math/big"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+var _ PayloadSigner = (*CryptoSigner)(nil)
+
+// CryptoSigner adapts any crypto.Signer (as returned by PKCS#11, cloud KMS,
+// TPM, or smart-card/YubiKey libraries) to the PayloadSigner interface, so that
+// the private key material never has to leave the external signer.
+//
+// The zero value is not usable; construct one with NewCryptoSigner.
+type CryptoSigner struct {
+	alg    jwa.SignatureAlgorithm
+	hash   crypto.Hash
+	signer crypto.Signer
+}
+
+// NewCryptoSigner creates a PayloadSigner that dispatches to the given crypto.Signer.
+// alg determines both the JWS algorithm advertised by Algorithm() and the
+// crypto.SignerOpts passed to signer.Sign: RS256/ES256/PS256 and friends
+// select crypto.SHA256, the 384/512 variants select the corresponding hash,
+// and PS256/384/512 additionally request PSS padding with a salt length
+// equal to the hash size, matching the RS/PS/ES signers elsewhere in this
+// package.
+func NewCryptoSigner(signer crypto.Signer, alg jwa.SignatureAlgorithm) (*CryptoSigner, error) {
+	hash, err := hashForAlgorithm(alg)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to determine hash for algorithm %q`, alg)
+	}
+
+	switch alg {
+	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+		if _, ok := signer.Public().(*rsa.PublicKey); !ok {
+			return nil, errors.Errorf(`algorithm %q requires an RSA public key, got %T`, alg, signer.Public())
+		}
+	case jwa.ES256, jwa.ES384, jwa.ES512:
+		if _, ok := signer.Public().(*ecdsa.PublicKey); !ok {
+			return nil, errors.Errorf(`algorithm %q requires an ECDSA public key, got %T`, alg, signer.Public())
+		}
+	default:
+		return nil, errors.Errorf(`jws.NewCryptoSigner: unsupported algorithm %q`, alg)
+	}
+
+	return &CryptoSigner{
+		alg:    alg,
+		hash:   hash,
+		signer: signer,
+	}, nil
+}
+
+func hashForAlgorithm(alg jwa.SignatureAlgorithm) (crypto.Hash, error) {
+	switch alg {
+	case jwa.RS256, jwa.ES256, jwa.PS256:
+		return crypto.SHA256, nil
+	case jwa.RS384, jwa.ES384, jwa.PS384:
+		return crypto.SHA384, nil
+	case jwa.RS512, jwa.ES512, jwa.PS512:
+		return crypto.SHA512, nil
+	default:
+		return 0, errors.Errorf(`no known hash for algorithm %q`, alg)
+	}
+}
+
+// Algorithm returns the JWS algorithm this signer was constructed with.
+func (s *CryptoSigner) Algorithm() jwa.SignatureAlgorithm {
+	return s.alg
+}
+
+// ProtectedHeader returns nil: CryptoSigner does not contribute any
+// protected header values of its own. It is implemented so that
+// CryptoSigner satisfies PayloadSigner and can be used anywhere a
+// PayloadSigner is expected, such as (*Builder).AddRecipient.
+func (s *CryptoSigner) ProtectedHeader() Headers {
+	return nil
+}
+
+// PublicHeader returns nil: CryptoSigner does not contribute any
+// unprotected header values of its own.
+func (s *CryptoSigner) PublicHeader() Headers {
+	return nil
+}
+
+// Sign hashes payload and delegates the actual signature generation to the
+// wrapped crypto.Signer, so that the private key itself never needs to be
+// held in process memory.
+func (s *CryptoSigner) Sign(payload []byte) ([]byte, error) {
+	h := s.hash.New()
+	h.Write(payload)
+	digest := h.Sum(nil)
+
+	opts := crypto.SignerOpts(s.hash)
+	switch s.alg {
+	case jwa.PS256, jwa.PS384, jwa.PS512:
+		opts = &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       s.hash,
+		}
+	}
+
+	signature, err := s.signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to sign payload via crypto.Signer`)
+	}
+
+	// A crypto.Signer backed by an ECDSA key returns an ASN.1 DER signature;
+	// JWS (RFC 7518 section 3.4) uses the fixed-size R || S form instead.
+	if pub, ok := s.signer.Public().(*ecdsa.PublicKey); ok {
+		var esig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(signature, &esig); err != nil {
+			return nil, errors.Wrap(err, `failed to parse ECDSA signature from crypto.Signer`)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		out := make([]byte, 2*size)
+		r, sv := esig.R.Bytes(), esig.S.Bytes()
+		copy(out[size-len(r):size], r)
+		copy(out[2*size-len(sv):], sv)
+		return out, nil
+	}
+	return signature, nil
+}
+
+// CryptoPublicKeyVerifier verifies signatures against a crypto.PublicKey of
+// any of the types supported elsewhere in this package (*rsa.PublicKey,
+// *ecdsa.PublicKey), without requiring the caller to know which concrete
+// verifier to instantiate.
+type CryptoPublicKeyVerifier struct {
+	alg jwa.SignatureAlgorithm
+}
+
+// NewCryptoPublicKeyVerifier creates a Verifier for alg that accepts a
+// crypto.PublicKey and dispatches to the appropriate concrete verifier
+// (RSAVerifier, ECDSAVerifier, ...) based on alg.
+func NewCryptoPublicKeyVerifier(alg jwa.SignatureAlgorithm) (*CryptoPublicKeyVerifier, error) {
+	if _, err := newVerifier(alg); err != nil {
+		return nil, errors.Wrapf(err, `unsupported algorithm %q`, alg)
+	}
+	return &CryptoPublicKeyVerifier{alg: alg}, nil
+}
+
+// Verify accepts key as a crypto.PublicKey (*rsa.PublicKey or *ecdsa.PublicKey,
+// depending on the configured algorithm) and dispatches to the concrete
+// verifier for the configured algorithm.
+func (v *CryptoPublicKeyVerifier) Verify(payload, signature []byte, key interface{}) error {
+	switch v.alg {
+	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+		if _, ok := key.(*rsa.PublicKey); !ok {
+			return errors.Errorf(`jws.CryptoPublicKeyVerifier: algorithm %q requires a *rsa.PublicKey, got %T`, v.alg, key)
+		}
+	case jwa.ES256, jwa.ES384, jwa.ES512:
+		if _, ok := key.(*ecdsa.PublicKey); !ok {
+			return errors.Errorf(`jws.CryptoPublicKeyVerifier: algorithm %q requires a *ecdsa.PublicKey, got %T`, v.alg, key)
+		}
+	default:
+		return errors.Errorf(`jws.CryptoPublicKeyVerifier: unsupported algorithm %q`, v.alg)
+	}
+
+	verifier, err := newVerifier(v.alg)
+	if err != nil {
+		return errors.Wrapf(err, `failed to create verifier for algorithm %q`, v.alg)
+	}
+	return verifier.Verify(payload, signature, key)
+}
@@ -0,0 +1,73 @@
+package jws
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCryptoSignerSatisfiesPayloadSigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err, `rsa.GenerateKey should succeed`) {
+		return
+	}
+
+	signer, err := NewCryptoSigner(key, jwa.RS256)
+	if !assert.NoError(t, err, `NewCryptoSigner should succeed`) {
+		return
+	}
+
+	var _ PayloadSigner = signer
+	assert.Nil(t, signer.ProtectedHeader(), `ProtectedHeader should be nil`)
+	assert.Nil(t, signer.PublicHeader(), `PublicHeader should be nil`)
+}
+
+func TestCryptoSignerSignAndVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err, `rsa.GenerateKey should succeed`) {
+		return
+	}
+
+	signer, err := NewCryptoSigner(key, jwa.RS256)
+	if !assert.NoError(t, err, `NewCryptoSigner should succeed`) {
+		return
+	}
+
+	payload := []byte(`hello world`)
+	signature, err := signer.Sign(payload)
+	if !assert.NoError(t, err, `Sign should succeed`) {
+		return
+	}
+
+	verifier, err := NewCryptoPublicKeyVerifier(jwa.RS256)
+	if !assert.NoError(t, err, `NewCryptoPublicKeyVerifier should succeed`) {
+		return
+	}
+
+	assert.NoError(t, verifier.Verify(payload, signature, &key.PublicKey), `Verify should succeed for the matching key`)
+}
+
+func TestCryptoPublicKeyVerifierRejectsWrongKeyType(t *testing.T) {
+	verifier, err := NewCryptoPublicKeyVerifier(jwa.RS256)
+	if !assert.NoError(t, err, `NewCryptoPublicKeyVerifier should succeed`) {
+		return
+	}
+
+	// An RS256 verifier must reject a non-RSA key outright, rather than
+	// forwarding it to the underlying verifier (which previously happened
+	// because crypto.PublicKey is interface{} and the assertion never failed).
+	err = verifier.Verify([]byte("payload"), []byte("signature"), "not a key")
+	assert.Error(t, err, `Verify should reject a key of the wrong type`)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err, `ecdsa.GenerateKey should succeed`) {
+		return
+	}
+	err = verifier.Verify([]byte("payload"), []byte("signature"), &ecKey.PublicKey)
+	assert.Error(t, err, `an RS256 verifier should reject an ECDSA public key`)
+}
@@ -0,0 +1,180 @@
+package jws
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// B64Key is the protected header field used to request RFC 7797 unencoded
+// payload mode. When present and false, the raw payload bytes (not their
+// base64url encoding) are used in the signing input, and the compact
+// serialization's payload segment is left empty. Only SignDetached and
+// VerifyDetached read it; Sign ignores it and always signs the
+// base64url-encoded payload.
+const B64Key = "b64"
+
+// CriticalKey is the protected header field ("crit") listing extension
+// header names that a verifier must understand and process, per RFC 7515
+// section 4.1.11.
+const CriticalKey = "crit"
+
+// SignDetached computes a JWS signature over payload the same way Sign
+// would, but returns the detached compact serialization described in
+// RFC 7515 appendix F: "<protected>..<signature>", with the payload
+// segment omitted. The caller is responsible for transmitting payload
+// alongside the signature and supplying it back to VerifyDetached.
+func SignDetached(payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
+	var opts signOptions
+	for _, o := range options {
+		o.apply(&opts)
+	}
+
+	signer, err := newPayloadSigner(alg, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to create signer for algorithm %q`, alg)
+	}
+
+	protected, err := applyNonce(opts.protected, opts.nonceSource)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to apply nonce`)
+	}
+	if protected == nil {
+		protected = NewHeaders()
+	}
+	if _, ok := protected.Get(AlgorithmKey); !ok {
+		if err := protected.Set(AlgorithmKey, alg); err != nil {
+			return nil, errors.Wrap(err, `failed to set "alg" header`)
+		}
+	}
+	if ph := signer.ProtectedHeader(); ph != nil {
+		if err := protected.Copy(ph); err != nil {
+			return nil, errors.Wrap(err, `failed to copy signer protected header`)
+		}
+	}
+
+	b64 := true
+	if v, ok := protected.Get(B64Key); ok {
+		if bv, ok := v.(bool); ok {
+			b64 = bv
+			if err := addCrit(protected, B64Key); err != nil {
+				return nil, errors.Wrap(err, `failed to add "b64" to "crit"`)
+			}
+		}
+	}
+
+	protectedBuf, err := json.Marshal(protected)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal protected header`)
+	}
+	encodedProtected := base64.RawURLEncoding.EncodeToString(protectedBuf)
+
+	signingInput := buildSigningInput(encodedProtected, payload, b64)
+
+	signature, err := signer.Sign(signingInput)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to sign payload`)
+	}
+
+	return bytes.Join(
+		[][]byte{
+			[]byte(encodedProtected),
+			nil,
+			[]byte(base64.RawURLEncoding.EncodeToString(signature)),
+		},
+		[]byte{'.'},
+	), nil
+}
+
+// VerifyDetached verifies the detached compact serialization produced by
+// SignDetached (or by any other RFC 7797/7515-appendix-F-compliant signer)
+// against payload, which must be supplied by the caller since it is not
+// present in signed. On success it returns payload unchanged.
+func VerifyDetached(signed []byte, payload []byte, alg jwa.SignatureAlgorithm, key interface{}) ([]byte, error) {
+	parts := bytes.Split(signed, []byte{'.'})
+	if len(parts) != 3 {
+		return nil, errors.New(`jws.VerifyDetached: invalid compact serialization`)
+	}
+	if len(parts[1]) != 0 {
+		return nil, errors.New(`jws.VerifyDetached: expected an empty payload segment in the detached serialization`)
+	}
+
+	protectedBuf, err := base64.RawURLEncoding.DecodeString(string(parts[0]))
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode protected header`)
+	}
+
+	protected := NewHeaders()
+	if err := json.Unmarshal(protectedBuf, protected); err != nil {
+		return nil, errors.Wrap(err, `failed to unmarshal protected header`)
+	}
+
+	if v, ok := protected.Get(AlgorithmKey); ok {
+		if headerAlg, ok := v.(jwa.SignatureAlgorithm); ok && headerAlg != alg {
+			return nil, errors.Errorf(`jws.VerifyDetached: alg mismatch: expected %q, got %q in protected header`, alg, headerAlg)
+		}
+	}
+
+	b64 := true
+	if v, ok := protected.Get(B64Key); ok {
+		if bv, ok := v.(bool); ok {
+			b64 = bv
+		}
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(string(parts[2]))
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode signature`)
+	}
+
+	signingInput := buildSigningInput(string(parts[0]), payload, b64)
+
+	verifier, err := newVerifier(alg)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to create verifier for algorithm %q`, alg)
+	}
+	if err := verifier.Verify(signingInput, signature, key); err != nil {
+		return nil, errors.Wrap(err, `failed to verify signature`)
+	}
+
+	return payload, nil
+}
+
+// buildSigningInput constructs the JWS signing input for a protected
+// header that has already been base64url-encoded. When b64 is false
+// (RFC 7797), the raw payload bytes are used verbatim instead of their
+// base64url encoding.
+func buildSigningInput(encodedProtected string, payload []byte, b64 bool) []byte {
+	payloadSegment := []byte(base64.RawURLEncoding.EncodeToString(payload))
+	if !b64 {
+		payloadSegment = payload
+	}
+	return bytes.Join(
+		[][]byte{
+			[]byte(encodedProtected),
+			payloadSegment,
+		},
+		[]byte{'.'},
+	)
+}
+
+// addCrit ensures name is present in the protected header's "crit" list,
+// creating the list if necessary.
+func addCrit(protected Headers, name string) error {
+	var crit []string
+	if v, ok := protected.Get(CriticalKey); ok {
+		if list, ok := v.([]string); ok {
+			crit = list
+		}
+	}
+	for _, v := range crit {
+		if v == name {
+			return nil
+		}
+	}
+	crit = append(crit, name)
+	return protected.Set(CriticalKey, crit)
+}
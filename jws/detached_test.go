@@ -0,0 +1,70 @@
+package jws
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignVerifyDetached(t *testing.T) {
+	secret := []byte("super-secret-key")
+	payload := []byte(`{"hello":"world"}`)
+
+	signed, err := SignDetached(payload, jwa.HS256, secret)
+	if !assert.NoError(t, err, `SignDetached should succeed`) {
+		return
+	}
+
+	parts := bytes.Split(signed, []byte{'.'})
+	if !assert.Len(t, parts, 3, `detached serialization should have 3 segments`) {
+		return
+	}
+	assert.Empty(t, parts[1], `the payload segment must be empty in detached mode`)
+
+	got, err := VerifyDetached(signed, payload, jwa.HS256, secret)
+	if assert.NoError(t, err, `VerifyDetached should succeed with the correct payload`) {
+		assert.Equal(t, payload, got)
+	}
+
+	_, err = VerifyDetached(signed, []byte(`{"tampered":true}`), jwa.HS256, secret)
+	assert.Error(t, err, `VerifyDetached should fail when the supplied payload does not match what was signed`)
+}
+
+// TestVerifyDetachedRejectsAlgMismatch is a regression test: VerifyDetached
+// must not trust the caller's alg argument alone and skip checking it
+// against what the protected header itself declares, or a message signed
+// with one algorithm could be replayed as if it were signed with another.
+func TestVerifyDetachedRejectsAlgMismatch(t *testing.T) {
+	secret := []byte("super-secret-key")
+	payload := []byte(`{"hello":"world"}`)
+
+	signed, err := SignDetached(payload, jwa.HS256, secret)
+	if !assert.NoError(t, err, `SignDetached should succeed`) {
+		return
+	}
+
+	_, err = VerifyDetached(signed, payload, jwa.HS384, secret)
+	assert.Error(t, err, `VerifyDetached should reject a caller-supplied alg that does not match the protected header's "alg"`)
+}
+
+func TestSignVerifyDetachedUnencodedPayload(t *testing.T) {
+	secret := []byte("super-secret-key")
+	payload := []byte("raw.binary-ish payload with a dot")
+
+	protected := NewHeaders()
+	if !assert.NoError(t, protected.Set(B64Key, false)) {
+		return
+	}
+
+	signed, err := SignDetached(payload, jwa.HS256, secret, WithProtectedHeaders(protected))
+	if !assert.NoError(t, err, `SignDetached should succeed in b64=false mode`) {
+		return
+	}
+
+	got, err := VerifyDetached(signed, payload, jwa.HS256, secret)
+	if assert.NoError(t, err, `VerifyDetached should succeed for the unencoded payload`) {
+		assert.Equal(t, payload, got)
+	}
+}
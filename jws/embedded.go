@@ -0,0 +1,285 @@
+package jws
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// JWKKey, X509CertChainKey, X509URLKey and JWKSetURLKey name the
+// "jwk", "x5c", "x5u" and "jku" protected header fields of RFC 7515
+// section 4.1. VerifyWithEmbeddedKey consults "jwk", "x5c" and "jku" (the
+// last only through a JWKSetFetcher installed via WithJWKSetFetcher); it
+// does not read "x5u".
+const (
+	JWKKey           = "jwk"
+	X509CertChainKey = "x5c"
+	X509URLKey       = "x5u"
+	JWKSetURLKey     = "jku"
+)
+
+// JWKSetFetcher retrieves the JWK set published at url, typically in
+// response to a "jku" protected header. Implementations are expected to
+// provide their own HTTP client configuration, caching, and allow-listing
+// of acceptable URLs; this package performs no network access on its own.
+type JWKSetFetcher func(ctx context.Context, url string) (jwk.Set, error)
+
+// EmbeddedKeyOption configures VerifyWithEmbeddedKey.
+type EmbeddedKeyOption interface {
+	embeddedKeyOption()
+	apply(*embeddedKeyOptions)
+}
+
+type embeddedKeyOptions struct {
+	ctx     context.Context
+	pool    *x509.CertPool
+	dnsName string
+	ekus    []x509.ExtKeyUsage
+	fetcher JWKSetFetcher
+}
+
+// defaultCertEKUs is used to validate an "x5c" chain when the caller does
+// not specify WithCertEKU. It covers the certificate types most likely to
+// be used to sign a JWS whose key travels with the token: client
+// authentication (ACME-style mTLS-adjacent flows) and code/artifact
+// signing. Callers with a narrower or different expectation should always
+// pass WithCertEKU explicitly.
+var defaultCertEKUs = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageCodeSigning}
+
+type embeddedKeyOptionFunc func(*embeddedKeyOptions)
+
+func (embeddedKeyOptionFunc) embeddedKeyOption() {}
+func (f embeddedKeyOptionFunc) apply(o *embeddedKeyOptions) {
+	f(o)
+}
+
+// WithContext sets the context used for any network access performed while
+// resolving the embedded key (currently, only calls made through a
+// JWKSetFetcher installed via WithJWKSetFetcher).
+func WithContext(ctx context.Context) EmbeddedKeyOption {
+	return embeddedKeyOptionFunc(func(o *embeddedKeyOptions) {
+		o.ctx = ctx
+	})
+}
+
+// WithCertPool supplies the trust root used to validate an "x5c" certificate
+// chain. It is required for VerifyWithEmbeddedKey to accept a signature
+// whose protected header carries "x5c".
+func WithCertPool(pool *x509.CertPool) EmbeddedKeyOption {
+	return embeddedKeyOptionFunc(func(o *embeddedKeyOptions) {
+		o.pool = pool
+	})
+}
+
+// WithCertDNSName additionally requires the leaf certificate in an "x5c"
+// chain to be valid for the given DNS name (via (*x509.Certificate).VerifyHostname).
+func WithCertDNSName(name string) EmbeddedKeyOption {
+	return embeddedKeyOptionFunc(func(o *embeddedKeyOptions) {
+		o.dnsName = name
+	})
+}
+
+// WithCertEKU restricts an "x5c" leaf certificate to one of the given
+// Extended Key Usages (the chain is accepted if the leaf allows any of
+// them, mirroring x509.VerifyOptions.KeyUsages). Without this option,
+// defaultCertEKUs is enforced; to intentionally accept any EKU, pass
+// WithCertEKU(x509.ExtKeyUsageAny) explicitly.
+func WithCertEKU(ekus ...x509.ExtKeyUsage) EmbeddedKeyOption {
+	return embeddedKeyOptionFunc(func(o *embeddedKeyOptions) {
+		o.ekus = ekus
+	})
+}
+
+// WithJWKSetFetcher installs the callback used to resolve a "jku" protected
+// header into a jwk.Set. Without this option, VerifyWithEmbeddedKey refuses
+// to follow "jku" headers.
+func WithJWKSetFetcher(fetcher JWKSetFetcher) EmbeddedKeyOption {
+	return embeddedKeyOptionFunc(func(o *embeddedKeyOptions) {
+		o.fetcher = fetcher
+	})
+}
+
+// VerifyWithEmbeddedKey verifies a compact-serialization JWS whose signer
+// key travels with the token, using whichever of "jwk", "x5c", or "jku" is
+// present in the protected header (checked in that order). "x5c" chains
+// are validated against the caller-supplied pool (WithCertPool) before the
+// leaf key is trusted; "jku" is only followed if a JWKSetFetcher has been
+// installed via WithJWKSetFetcher, so that callers retain full control over
+// outbound HTTP, caching, and host allow-listing.
+func VerifyWithEmbeddedKey(signed []byte, options ...EmbeddedKeyOption) ([]byte, error) {
+	opts := embeddedKeyOptions{
+		ctx: context.Background(),
+	}
+	for _, o := range options {
+		o.apply(&opts)
+	}
+
+	protected, payload, signature, alg, err := splitCompact(signed)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse compact serialization`)
+	}
+
+	key, err := resolveEmbeddedKey(opts, protected)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to resolve embedded key`)
+	}
+
+	verifier, err := newVerifier(alg)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to create verifier for algorithm %q`, alg)
+	}
+	if err := verifier.Verify(signingInputFromParts(protected, payload), signature, key); err != nil {
+		return nil, errors.Wrap(err, `failed to verify signature`)
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(string(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode payload`)
+	}
+	return decoded, nil
+}
+
+func resolveEmbeddedKey(opts embeddedKeyOptions, protected Headers) (interface{}, error) {
+	if v, ok := protected.Get(JWKKey); ok {
+		key, ok := v.(jwk.Key)
+		if !ok {
+			return nil, errors.Errorf(`"jwk" header has unexpected type %T`, v)
+		}
+		return jwk.PublicKeyOf(key)
+	}
+
+	if v, ok := protected.Get(X509CertChainKey); ok {
+		chain, ok := v.([]string)
+		if !ok {
+			return nil, errors.Errorf(`"x5c" header has unexpected type %T`, v)
+		}
+		return verifyX509Chain(opts, chain)
+	}
+
+	if v, ok := protected.Get(JWKSetURLKey); ok {
+		url, ok := v.(string)
+		if !ok {
+			return nil, errors.Errorf(`"jku" header has unexpected type %T`, v)
+		}
+		if opts.fetcher == nil {
+			return nil, errors.New(`"jku" header present but no JWKSetFetcher was configured (see WithJWKSetFetcher)`)
+		}
+		set, err := opts.fetcher(opts.ctx, url)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to fetch JWK set from %q`, url)
+		}
+
+		var kid string
+		if v, ok := protected.Get(KeyIDKey); ok {
+			kid, _ = v.(string)
+		}
+		key, ok := set.LookupKeyID(kid)
+		if !ok {
+			return nil, errors.Errorf(`key with kid %q not found in JWK set fetched from %q`, kid, url)
+		}
+		return jwk.PublicKeyOf(key)
+	}
+
+	return nil, errors.New(`protected header contains none of "jwk", "x5c", "jku"`)
+}
+
+func verifyX509Chain(opts embeddedKeyOptions, chain []string) (interface{}, error) {
+	if opts.pool == nil {
+		return nil, errors.New(`"x5c" header present but no trust root was configured (see WithCertPool)`)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New(`"x5c" header is empty`)
+	}
+
+	certs := make([]*x509.Certificate, len(chain))
+	for i, encoded := range chain {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to base64-decode x5c[%d]`, i)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to parse x5c[%d]`, i)
+		}
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	ekus := opts.ekus
+	if ekus == nil {
+		ekus = defaultCertEKUs
+	}
+
+	leaf := certs[0]
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         opts.pool,
+		Intermediates: intermediates,
+		KeyUsages:     ekus,
+	}); err != nil {
+		return nil, errors.Wrap(err, `failed to verify x5c certificate chain`)
+	}
+
+	if opts.dnsName != "" {
+		if err := leaf.VerifyHostname(opts.dnsName); err != nil {
+			return nil, errors.Wrap(err, `x5c leaf certificate failed hostname verification`)
+		}
+	}
+
+	return leaf.PublicKey, nil
+}
+
+// splitCompact decodes a compact-serialization JWS into its protected
+// header, its raw (still base64url-encoded) payload segment, its decoded
+// signature, and the algorithm declared by the protected header.
+func splitCompact(signed []byte) (protected Headers, payload []byte, signature []byte, alg jwa.SignatureAlgorithm, err error) {
+	parts := bytes.Split(signed, []byte{'.'})
+	if len(parts) != 3 {
+		return nil, nil, nil, alg, errors.New(`invalid number of segments`)
+	}
+
+	protectedBuf, err := base64.RawURLEncoding.DecodeString(string(parts[0]))
+	if err != nil {
+		return nil, nil, nil, alg, errors.Wrap(err, `failed to decode protected header`)
+	}
+
+	protected = NewHeaders()
+	if err := json.Unmarshal(protectedBuf, protected); err != nil {
+		return nil, nil, nil, alg, errors.Wrap(err, `failed to unmarshal protected header`)
+	}
+
+	if v, ok := protected.Get(AlgorithmKey); ok {
+		if a, ok := v.(jwa.SignatureAlgorithm); ok {
+			alg = a
+		}
+	}
+
+	signature, err = base64.RawURLEncoding.DecodeString(string(parts[2]))
+	if err != nil {
+		return nil, nil, nil, alg, errors.Wrap(err, `failed to decode signature`)
+	}
+
+	return protected, parts[1], signature, alg, nil
+}
+
+func signingInputFromParts(protected Headers, payload []byte) []byte {
+	protectedBuf, _ := json.Marshal(protected)
+	return append(append(base64Encode(protectedBuf), '.'), payload...)
+}
+
+func base64Encode(buf []byte) []byte {
+	return []byte(base64.RawURLEncoding.EncodeToString(buf))
+}
+
+func base64Decode(buf []byte) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(string(buf))
+}
@@ -0,0 +1,78 @@
+package jws
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func selfSignedCert(t *testing.T, ekus []x509.ExtKeyUsage) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "jws-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           ekus,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return cert, key
+}
+
+func TestVerifyX509ChainEnforcesEKU(t *testing.T) {
+	cert, _ := selfSignedCert(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth})
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	chain := []string{certToBase64(cert)}
+
+	opts := embeddedKeyOptions{pool: pool}
+	_, err := verifyX509Chain(opts, chain)
+	assert.Error(t, err, `a ServerAuth-only cert should be rejected by the default EKU set (ClientAuth/CodeSigning)`)
+
+	opts.ekus = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	_, err = verifyX509Chain(opts, chain)
+	assert.NoError(t, err, `explicitly allowing ServerAuth via WithCertEKU should accept the cert`)
+}
+
+func TestVerifyX509ChainAcceptsDefaultEKU(t *testing.T) {
+	cert, _ := selfSignedCert(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	opts := embeddedKeyOptions{pool: pool}
+	_, err := verifyX509Chain(opts, []string{certToBase64(cert)})
+	assert.NoError(t, err, `a ClientAuth cert should satisfy the default EKU set`)
+}
+
+func certToBase64(cert *x509.Certificate) string {
+	return base64.StdEncoding.EncodeToString(cert.Raw)
+}
@@ -58,9 +58,12 @@ func (f JWKAcceptFunc) Accept(key jwk.Key) bool {
 }
 
 // DefaultJWKAcceptor is the default acceptor that is used
-// in functions like VerifyWithJWKSet
+// in functions like VerifyWithJWKSet. A key is accepted if it declares no
+// "use" at all, or declares "use" as exactly "sig" -- a key explicitly
+// marked "enc" (or anything else) is rejected, since such a key was never
+// meant to be used for verifying signatures.
 var DefaultJWKAcceptor = JWKAcceptFunc(func(key jwk.Key) bool {
-	if u := key.KeyUsage(); u != "" && u != "enc" && u != "sig" {
+	if u := key.KeyUsage(); u != "" && u != "sig" {
 		return false
 	}
 	return true
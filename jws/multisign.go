@@ -0,0 +1,268 @@
+package jws
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// AlgorithmKey and KeyIDKey name the protected header fields ("alg", "kid")
+// used throughout this package to identify the signing algorithm and key.
+const (
+	AlgorithmKey = "alg"
+	KeyIDKey     = "kid"
+)
+
+// RecipientOption describes options that may be passed to (*Builder).AddRecipient
+// to control the protected and unprotected headers used for that particular
+// recipient's signature.
+type RecipientOption interface {
+	recipientOption()
+	apply(*recipientOptions)
+}
+
+type recipientOptions struct {
+	protected   Headers
+	unprotected Headers
+}
+
+type recipientOptionFunc func(*recipientOptions)
+
+func (recipientOptionFunc) recipientOption() {}
+func (f recipientOptionFunc) apply(o *recipientOptions) {
+	f(o)
+}
+
+// WithRecipientProtectedHeaders specifies the protected header values
+// (e.g. kid, jwk, x5c, crit) to merge into the recipient's signature.
+// Values set here take precedence over the ones the Builder would
+// otherwise derive (such as "alg").
+func WithRecipientProtectedHeaders(h Headers) RecipientOption {
+	return recipientOptionFunc(func(o *recipientOptions) {
+		o.protected = h
+	})
+}
+
+// WithRecipientHeaders specifies the unprotected (per-signature) header
+// values to attach to the recipient's signature.
+func WithRecipientHeaders(h Headers) RecipientOption {
+	return recipientOptionFunc(func(o *recipientOptions) {
+		o.unprotected = h
+	})
+}
+
+type recipient struct {
+	signer    PayloadSigner
+	protected Headers
+	headers   Headers
+}
+
+// Builder assembles a JWS message signed by one or more recipients, each of
+// which may use a different algorithm and key and carry its own protected
+// and unprotected headers. Unlike Sign, which always produces a single
+// signature, Builder always produces the JWS JSON general serialization
+// (RFC 7515 section 7.2.1): a "payload" member plus a "signatures" array.
+//
+// AddRecipient and Sign may be called concurrently from multiple
+// goroutines; both are guarded by an internal mutex, which Sign holds for
+// the duration of the call and AddRecipient while registering the recipient.
+type Builder struct {
+	mu         sync.Mutex
+	recipients []*recipient
+}
+
+// NewBuilder creates an empty Builder. Recipients are added via AddRecipient
+// before calling Sign.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// AddRecipient registers a key that will produce one signature entry in the
+// Message returned by Sign. alg and key are used exactly as they would be
+// for a single-recipient Sign call.
+func (b *Builder) AddRecipient(alg jwa.SignatureAlgorithm, key interface{}, options ...RecipientOption) error {
+	signer, err := newPayloadSigner(alg, key)
+	if err != nil {
+		return errors.Wrapf(err, `failed to create signer for algorithm %q`, alg)
+	}
+
+	var opts recipientOptions
+	for _, o := range options {
+		o.apply(&opts)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.recipients = append(b.recipients, &recipient{
+		signer:    signer,
+		protected: opts.protected,
+		headers:   opts.unprotected,
+	})
+	return nil
+}
+
+// Sign computes one signature per registered recipient and returns the
+// resulting Message. AddRecipient must be called at least once beforehand.
+func (b *Builder) Sign(payload []byte) (*Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.recipients) == 0 {
+		return nil, errors.New(`jws.Builder: Sign requires at least one recipient (see AddRecipient)`)
+	}
+
+	msg := &Message{
+		payload: payload,
+	}
+	for i, r := range b.recipients {
+		sig, err := signOneRecipient(payload, r)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to generate signature for recipient %d`, i)
+		}
+		msg.signatures = append(msg.signatures, sig)
+	}
+	return msg, nil
+}
+
+func signOneRecipient(payload []byte, r *recipient) (*Signature, error) {
+	// Build our own Headers rather than mutating r.protected in place: the
+	// same Headers value passed to WithRecipientProtectedHeaders may be
+	// shared across multiple AddRecipient calls (e.g. common headers plus
+	// a per-recipient alg/key), and mutating it here would leak one
+	// recipient's "alg" into another's.
+	protected := NewHeaders()
+	if r.protected != nil {
+		if err := protected.Copy(r.protected); err != nil {
+			return nil, errors.Wrap(err, `failed to copy recipient protected header`)
+		}
+	}
+	if _, ok := protected.Get(AlgorithmKey); !ok {
+		if err := protected.Set(AlgorithmKey, r.signer.Algorithm()); err != nil {
+			return nil, errors.Wrap(err, `failed to set "alg" header`)
+		}
+	}
+	if ph := r.signer.ProtectedHeader(); ph != nil {
+		if err := protected.Copy(ph); err != nil {
+			return nil, errors.Wrap(err, `failed to copy signer protected header`)
+		}
+	}
+
+	protectedBuf, err := json.Marshal(protected)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal protected header`)
+	}
+
+	signingInput := bytes.Join(
+		[][]byte{
+			[]byte(base64.RawURLEncoding.EncodeToString(protectedBuf)),
+			[]byte(base64.RawURLEncoding.EncodeToString(payload)),
+		},
+		[]byte{'.'},
+	)
+
+	signature, err := r.signer.Sign(signingInput)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to sign payload`)
+	}
+
+	var headers Headers
+	if ph := r.signer.PublicHeader(); ph != nil {
+		headers = NewHeaders()
+		if r.headers != nil {
+			if err := headers.Copy(r.headers); err != nil {
+				return nil, errors.Wrap(err, `failed to copy recipient header`)
+			}
+		}
+		if err := headers.Copy(ph); err != nil {
+			return nil, errors.Wrap(err, `failed to copy signer public header`)
+		}
+	} else {
+		headers = r.headers
+	}
+
+	return &Signature{
+		headers:   headers,
+		protected: protected,
+		signature: signature,
+	}, nil
+}
+
+// VerifyResult describes which recipient, out of a multi-signature Message,
+// successfully verified the payload.
+type VerifyResult struct {
+	Index     int
+	Signature *Signature
+	Payload   []byte
+}
+
+// VerifyMulti verifies a general-serialization JWS message by trying each
+// recipient's signature in turn. It returns as soon as one recipient's
+// signature verifies; the matching recipient is reported in the returned
+// VerifyResult. keyFunc is consulted once per candidate signature and is
+// expected to return the key material (or nil, false to skip) for the
+// kid/alg pair found in that signature's protected header.
+func VerifyMulti(buf []byte, keyFunc func(kid string, alg jwa.SignatureAlgorithm) (interface{}, bool)) (*VerifyResult, error) {
+	msg, err := parseGeneral(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse general serialization JWS message`)
+	}
+
+	for i, sig := range msg.signatures {
+		alg, kid := sig.signatureAlgAndKid()
+		key, ok := keyFunc(kid, alg)
+		if !ok {
+			continue
+		}
+
+		verifier, err := newVerifier(alg)
+		if err != nil {
+			continue
+		}
+
+		protectedBuf, err := json.Marshal(sig.protected)
+		if err != nil {
+			continue
+		}
+		signingInput := bytes.Join(
+			[][]byte{
+				[]byte(base64.RawURLEncoding.EncodeToString(protectedBuf)),
+				[]byte(base64.RawURLEncoding.EncodeToString(msg.payload)),
+			},
+			[]byte{'.'},
+		)
+
+		if err := verifier.Verify(signingInput, sig.signature, key); err != nil {
+			continue
+		}
+
+		return &VerifyResult{
+			Index:     i,
+			Signature: sig,
+			Payload:   msg.payload,
+		}, nil
+	}
+
+	return nil, errors.New(`jws.VerifyMulti: no recipient signature could be verified`)
+}
+
+func (s *Signature) signatureAlgAndKid() (jwa.SignatureAlgorithm, string) {
+	var alg jwa.SignatureAlgorithm
+	var kid string
+	if s.protected != nil {
+		if v, ok := s.protected.Get(AlgorithmKey); ok {
+			if a, ok := v.(jwa.SignatureAlgorithm); ok {
+				alg = a
+			}
+		}
+		if v, ok := s.protected.Get(KeyIDKey); ok {
+			if k, ok := v.(string); ok {
+				kid = k
+			}
+		}
+	}
+	return alg, kid
+}
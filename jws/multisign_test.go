@@ -0,0 +1,109 @@
+package jws
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilderSignMultipleRecipients(t *testing.T) {
+	secretA := []byte("recipient-a-secret")
+	secretB := []byte("recipient-b-secret")
+	payload := []byte(`{"hello":"world"}`)
+
+	b := NewBuilder()
+	if !assert.NoError(t, b.AddRecipient(jwa.HS256, secretA, WithRecipientProtectedHeaders(headersWithKid("a")))) {
+		return
+	}
+	if !assert.NoError(t, b.AddRecipient(jwa.HS256, secretB, WithRecipientProtectedHeaders(headersWithKid("b")))) {
+		return
+	}
+
+	msg, err := b.Sign(payload)
+	if !assert.NoError(t, err, `Sign should succeed`) {
+		return
+	}
+	if !assert.Len(t, msg.signatures, 2, `Sign should produce one signature per recipient`) {
+		return
+	}
+
+	secrets := []([]byte){secretA, secretB}
+	kids := []string{"a", "b"}
+	for i, sig := range msg.signatures {
+		kid, ok := sig.protected.Get(KeyIDKey)
+		if !assert.True(t, ok, `recipient %d should carry its "kid"`, i) {
+			continue
+		}
+		assert.Equal(t, kids[i], kid)
+
+		verifier, err := newVerifier(jwa.HS256)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		protectedBuf, err := json.Marshal(sig.protected)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		signingInput := buildSigningInput(base64.RawURLEncoding.EncodeToString(protectedBuf), payload, true)
+		assert.NoError(t, verifier.Verify(signingInput, sig.signature, secrets[i]), `recipient %d's signature should verify with its own key`, i)
+		assert.Error(t, verifier.Verify(signingInput, sig.signature, secrets[(i+1)%2]), `recipient %d's signature should not verify with the other recipient's key`, i)
+	}
+}
+
+// TestBuilderDoesNotMutateSharedRecipientHeaders is a regression test: a
+// single Headers instance passed to WithRecipientProtectedHeaders for more
+// than one recipient (a natural way to share common headers across
+// recipients) must not have one recipient's "alg" leak into another's,
+// since signOneRecipient used to set AlgorithmKey directly on the caller's
+// Headers value.
+func TestBuilderDoesNotMutateSharedRecipientHeaders(t *testing.T) {
+	secretA := []byte("recipient-a-secret")
+	secretB := []byte("recipient-b-secret")
+	shared := NewHeaders()
+	if !assert.NoError(t, shared.Set("custom", "value")) {
+		return
+	}
+
+	b := NewBuilder()
+	if !assert.NoError(t, b.AddRecipient(jwa.HS256, secretA, WithRecipientProtectedHeaders(shared))) {
+		return
+	}
+	if !assert.NoError(t, b.AddRecipient(jwa.HS384, secretB, WithRecipientProtectedHeaders(shared))) {
+		return
+	}
+
+	msg, err := b.Sign([]byte("payload"))
+	if !assert.NoError(t, err, `Sign should succeed`) {
+		return
+	}
+	if !assert.Len(t, msg.signatures, 2) {
+		return
+	}
+
+	algA, ok := msg.signatures[0].protected.Get(AlgorithmKey)
+	if assert.True(t, ok) {
+		assert.Equal(t, jwa.HS256, algA, `the first recipient's header must declare its own alg`)
+	}
+	algB, ok := msg.signatures[1].protected.Get(AlgorithmKey)
+	if assert.True(t, ok) {
+		assert.Equal(t, jwa.HS384, algB, `the second recipient's header must declare its own alg, not the first recipient's`)
+	}
+
+	_, ok = shared.Get(AlgorithmKey)
+	assert.False(t, ok, `the shared Headers instance passed by the caller must not be mutated`)
+}
+
+func TestBuilderSignRequiresRecipients(t *testing.T) {
+	b := NewBuilder()
+	_, err := b.Sign([]byte("payload"))
+	assert.Error(t, err, `Sign should fail when no recipients were added`)
+}
+
+func headersWithKid(kid string) Headers {
+	h := NewHeaders()
+	_ = h.Set(KeyIDKey, kid)
+	return h
+}
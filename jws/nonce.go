@@ -0,0 +1,137 @@
+package jws
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// NonceKey is the protected header field into which the value returned by
+// a NonceSource is written by WithNonceSource.
+const NonceKey = "nonce"
+
+// NonceSource supplies a fresh, single-use nonce for each JWS signing
+// operation. This is the pattern ACME clients (RFC 8555) use to obtain a
+// replay-protection nonce from the server before signing a request.
+type NonceSource interface {
+	Nonce() (string, error)
+}
+
+// SignOption describes options that may be passed to Sign to control the
+// headers attached to the resulting JWS.
+type SignOption interface {
+	signOption()
+	apply(*signOptions)
+}
+
+type signOptions struct {
+	nonceSource NonceSource
+	protected   Headers
+}
+
+type signOptionFunc func(*signOptions)
+
+func (signOptionFunc) signOption() {}
+func (f signOptionFunc) apply(o *signOptions) {
+	f(o)
+}
+
+// WithNonceSource causes Sign to fetch a fresh nonce from src and set it as
+// the "nonce" protected header on every signature it produces.
+func WithNonceSource(src NonceSource) SignOption {
+	return signOptionFunc(func(o *signOptions) {
+		o.nonceSource = src
+	})
+}
+
+// WithProtectedHeaders specifies additional protected header values (such
+// as "url", "nonce", or an embedded "jwk") to merge into the protected
+// header before signing; h itself is not modified. Values already present
+// in h take precedence over ones Sign would otherwise derive (such as
+// "alg"), except that a nonce obtained through WithNonceSource replaces any
+// "nonce" in h, and values from the signer's own protected header replace
+// those in h.
+func WithProtectedHeaders(h Headers) SignOption {
+	return signOptionFunc(func(o *signOptions) {
+		o.protected = h
+	})
+}
+
+// Sign computes a compact-serialization JWS signature over payload using
+// alg and key, honoring options such as WithNonceSource and
+// WithProtectedHeaders. This is the entry point ACME-style clients should
+// use: passing WithNonceSource causes every call to embed a fresh
+// replay-protection nonce, and WithProtectedHeaders lets the caller attach
+// request-specific values (such as "url") alongside it, removing the need
+// to build the protected header by hand.
+func Sign(payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
+	var opts signOptions
+	for _, o := range options {
+		o.apply(&opts)
+	}
+
+	signer, err := newPayloadSigner(alg, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to create signer for algorithm %q`, alg)
+	}
+
+	protected, err := applyNonce(opts.protected, opts.nonceSource)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to apply nonce`)
+	}
+	if protected == nil {
+		protected = NewHeaders()
+	}
+	if _, ok := protected.Get(AlgorithmKey); !ok {
+		if err := protected.Set(AlgorithmKey, alg); err != nil {
+			return nil, errors.Wrap(err, `failed to set "alg" header`)
+		}
+	}
+	if ph := signer.ProtectedHeader(); ph != nil {
+		if err := protected.Copy(ph); err != nil {
+			return nil, errors.Wrap(err, `failed to copy signer protected header`)
+		}
+	}
+
+	protectedBuf, err := json.Marshal(protected)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal protected header`)
+	}
+	encodedProtected := base64.RawURLEncoding.EncodeToString(protectedBuf)
+
+	signingInput := buildSigningInput(encodedProtected, payload, true)
+	signature, err := signer.Sign(signingInput)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to sign payload`)
+	}
+
+	return []byte(encodedProtected + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signature)), nil
+}
+
+// applyNonce returns a new Headers holding a copy of protected (which may be
+// nil) and, if src is set, a nonce fetched from src stored under NonceKey.
+// protected itself is never modified.
+func applyNonce(protected Headers, src NonceSource) (Headers, error) {
+	merged := NewHeaders()
+	if protected != nil {
+		if err := merged.Copy(protected); err != nil {
+			return nil, errors.Wrap(err, `failed to copy protected header`)
+		}
+	}
+	protected = merged
+	if src == nil {
+		return protected, nil
+	}
+
+	nonce, err := src.Nonce()
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to obtain nonce from NonceSource`)
+	}
+
+	if err := protected.Set(NonceKey, nonce); err != nil {
+		return nil, errors.Wrap(err, `failed to set "nonce" header`)
+	}
+	return protected, nil
+}
@@ -0,0 +1,82 @@
+package jws
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticNonceSource string
+
+func (s staticNonceSource) Nonce() (string, error) {
+	return string(s), nil
+}
+
+func TestSignWithNonceSource(t *testing.T) {
+	secret := []byte("super-secret-key")
+	payload := []byte(`{"hello":"world"}`)
+
+	signed, err := Sign(payload, jwa.HS256, secret, WithNonceSource(staticNonceSource("abc123")))
+	if !assert.NoError(t, err, `Sign should succeed`) {
+		return
+	}
+
+	protected, _, signature, alg, err := splitCompact(signed)
+	if !assert.NoError(t, err, `splitCompact should succeed`) {
+		return
+	}
+	assert.Equal(t, jwa.HS256, alg, `alg should be HS256`)
+
+	nonce, ok := protected.Get(NonceKey)
+	if !assert.True(t, ok, `protected header should carry a "nonce"`) {
+		return
+	}
+	assert.Equal(t, "abc123", nonce, `"nonce" should come from the NonceSource`)
+
+	verifier, err := newVerifier(jwa.HS256)
+	if !assert.NoError(t, err, `newVerifier should succeed`) {
+		return
+	}
+
+	protectedBuf, err := json.Marshal(protected)
+	if !assert.NoError(t, err) {
+		return
+	}
+	signingInput := buildSigningInput(base64.RawURLEncoding.EncodeToString(protectedBuf), payload, true)
+	assert.NoError(t, verifier.Verify(signingInput, signature, secret), `the resulting JWS should verify`)
+}
+
+func TestSignWithProtectedHeaders(t *testing.T) {
+	secret := []byte("super-secret-key")
+	payload := []byte(`{"hello":"world"}`)
+
+	extra := NewHeaders()
+	if err := extra.Set("url", "https://acme.example.com/new-order"); err != nil {
+		t.Fatalf(`failed to set "url" header: %s`, err)
+	}
+
+	signed, err := Sign(payload, jwa.HS256, secret, WithProtectedHeaders(extra), WithNonceSource(staticNonceSource("xyz789")))
+	if !assert.NoError(t, err, `Sign should succeed`) {
+		return
+	}
+
+	protected, _, _, _, err := splitCompact(signed)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	url, ok := protected.Get("url")
+	if !assert.True(t, ok, `protected header should carry "url"`) {
+		return
+	}
+	assert.Equal(t, "https://acme.example.com/new-order", url)
+
+	nonce, ok := protected.Get(NonceKey)
+	if !assert.True(t, ok, `protected header should still carry "nonce"`) {
+		return
+	}
+	assert.Equal(t, "xyz789", nonce)
+}
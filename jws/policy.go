@@ -0,0 +1,240 @@
+package jws
+
+import (
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// VerifyOption describes options that may be passed to Verify and
+// VerifyWithJWKSet to control the VerifyPolicy enforced before a signature
+// is dispatched to a Verifier.
+type VerifyOption interface {
+	verifyOption()
+	apply(*VerifyPolicy)
+}
+
+type verifyOptionFunc func(*VerifyPolicy)
+
+func (verifyOptionFunc) verifyOption() {}
+func (f verifyOptionFunc) apply(p *VerifyPolicy) {
+	f(p)
+}
+
+// VerifyPolicy describes restrictions that must hold for a JWS signature to
+// be accepted, independent of whether the cryptographic signature itself
+// is valid. A policy built by NewVerifyPolicy rejects "none" unless
+// WithRejectNone(false) is given, and requires no particular algorithm or
+// kid unless asked to. The zero value enforces nothing, not even the
+// "none" rejection.
+type VerifyPolicy struct {
+	allowedAlgorithms []jwa.SignatureAlgorithm
+	requireKid        bool
+	rejectNone        bool
+}
+
+// WithAllowedAlgorithms restricts Verify to only accept signatures using
+// one of the given algorithms. Use this to avoid algorithm-confusion
+// attacks where a token signed with, say, HS256 is presented to a verifier
+// that expects RS256 and would otherwise try every registered algorithm.
+func WithAllowedAlgorithms(algs []jwa.SignatureAlgorithm) VerifyOption {
+	return verifyOptionFunc(func(p *VerifyPolicy) {
+		p.allowedAlgorithms = algs
+	})
+}
+
+// WithRequireKid requires that the protected header carry a non-empty "kid"
+// so that key lookup is unambiguous.
+func WithRequireKid(v bool) VerifyOption {
+	return verifyOptionFunc(func(p *VerifyPolicy) {
+		p.requireKid = v
+	})
+}
+
+// WithRejectNone controls whether alg="none" is rejected outright. This
+// defaults to true and is only exposed so that callers who have a very
+// specific, audited need for unsecured JWS (RFC 7515 appendix A.5) can opt
+// back out.
+func WithRejectNone(v bool) VerifyOption {
+	return verifyOptionFunc(func(p *VerifyPolicy) {
+		p.rejectNone = v
+	})
+}
+
+// NewVerifyPolicy builds a VerifyPolicy from the given options. By default
+// "none" is rejected; all other restrictions are opt-in.
+func NewVerifyPolicy(options ...VerifyOption) *VerifyPolicy {
+	p := &VerifyPolicy{
+		rejectNone: true,
+	}
+	for _, o := range options {
+		o.apply(p)
+	}
+	return p
+}
+
+// Enforce checks alg and kid (as found in a candidate signature's protected
+// header) against the policy, returning an error describing the first
+// violation found.
+func (p *VerifyPolicy) Enforce(alg jwa.SignatureAlgorithm, kid string) error {
+	if p == nil {
+		return nil
+	}
+
+	if p.rejectNone && alg == jwa.NoSignature {
+		return errors.New(`jws: alg="none" is not permitted by the verification policy`)
+	}
+
+	if len(p.allowedAlgorithms) > 0 {
+		var found bool
+		for _, allowed := range p.allowedAlgorithms {
+			if allowed == alg {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf(`jws: algorithm %q is not in the allowed algorithm list`, alg)
+		}
+	}
+
+	if p.requireKid && kid == "" {
+		return errors.New(`jws: "kid" is required by the verification policy but is missing`)
+	}
+
+	return nil
+}
+
+// Verify checks the compact-serialization JWS in signed against key, which
+// must match alg. Before the cryptographic signature is checked, the
+// options (if any) are compiled into a VerifyPolicy and enforced against
+// the protected header's "alg" and "kid" -- this is what rejects
+// alg="none" (unless WithRejectNone(false) is given), and unexpected
+// algorithms or a missing "kid" when the caller asked for those
+// restrictions via WithAllowedAlgorithms/WithRequireKid.
+func Verify(signed []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...VerifyOption) ([]byte, error) {
+	policy := NewVerifyPolicy(options...)
+
+	protected, payload, signature, headerAlg, err := splitCompact(signed)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse compact serialization`)
+	}
+
+	if headerAlg != alg {
+		return nil, errors.Errorf(`jws.Verify: alg mismatch: expected %q, got %q in protected header`, alg, headerAlg)
+	}
+
+	if err := policy.Enforce(headerAlg, kidFromHeaders(protected)); err != nil {
+		return nil, errors.Wrap(err, `rejected by verification policy`)
+	}
+
+	verifier, err := newVerifier(alg)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to create verifier for algorithm %q`, alg)
+	}
+	if err := verifier.Verify(signingInputFromParts(protected, payload), signature, key); err != nil {
+		return nil, errors.Wrap(err, `failed to verify signature`)
+	}
+
+	decoded, err := base64Decode(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode payload`)
+	}
+	return decoded, nil
+}
+
+// VerifyWithJWKSet checks the compact-serialization JWS in signed against
+// each key in set that acceptor accepts. When the protected header carries
+// a "kid", only keys with that same "kid" are tried. As with
+// Verify, options are compiled into a VerifyPolicy and enforced before any
+// key is tried.
+//
+// If acceptor is nil, StrictJWKAcceptor(alg) is used, where alg is the
+// algorithm declared by signed's own protected header. This -- rather than
+// DefaultJWKAcceptor -- is the default because the whole point of
+// iterating a JWK set is to let attacker-controlled input select a key;
+// without requiring the key's declared "alg" to match, a key published for
+// one algorithm (e.g. RSA) can be replayed against a verifier expecting
+// another (e.g. HMAC), the classic algorithm-confusion attack.
+func VerifyWithJWKSet(signed []byte, set jwk.Set, acceptor JWKAcceptor, options ...VerifyOption) ([]byte, error) {
+	policy := NewVerifyPolicy(options...)
+
+	protected, payload, signature, alg, err := splitCompact(signed)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse compact serialization`)
+	}
+
+	if acceptor == nil {
+		acceptor = StrictJWKAcceptor(alg)
+	}
+
+	kid := kidFromHeaders(protected)
+	if err := policy.Enforce(alg, kid); err != nil {
+		return nil, errors.Wrap(err, `rejected by verification policy`)
+	}
+
+	verifier, err := newVerifier(alg)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to create verifier for algorithm %q`, alg)
+	}
+	signingInput := signingInputFromParts(protected, payload)
+
+	for i := 0; i < set.Len(); i++ {
+		key, ok := set.Get(i)
+		if !ok {
+			continue
+		}
+		if kid != "" && key.KeyID() != kid {
+			continue
+		}
+		if !acceptor.Accept(key) {
+			continue
+		}
+
+		pub, err := jwk.PublicKeyOf(key)
+		if err != nil {
+			continue
+		}
+		if err := verifier.Verify(signingInput, signature, pub); err != nil {
+			continue
+		}
+
+		decoded, err := base64Decode(payload)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to decode payload`)
+		}
+		return decoded, nil
+	}
+
+	return nil, errors.New(`jws.VerifyWithJWKSet: no acceptable key in the set could verify the signature`)
+}
+
+func kidFromHeaders(h Headers) string {
+	if h == nil {
+		return ""
+	}
+	if v, ok := h.Get(KeyIDKey); ok {
+		if kid, ok := v.(string); ok {
+			return kid
+		}
+	}
+	return ""
+}
+
+// StrictJWKAcceptor is a JWKAcceptor that, unlike DefaultJWKAcceptor,
+// requires the key to declare "use" as exactly "sig" (a key with no
+// declared "use" is rejected) and, when the key declares an "alg", requires it to match the
+// algorithm the caller intends to verify with. Use this in place of
+// DefaultJWKAcceptor when accepting keys from third-party JWK sets to avoid
+// algorithm-confusion and wrong-key-use acceptance.
+func StrictJWKAcceptor(alg jwa.SignatureAlgorithm) JWKAcceptor {
+	return JWKAcceptFunc(func(key jwk.Key) bool {
+		if key.KeyUsage() != "sig" {
+			return false
+		}
+		if declared := key.Algorithm(); declared != "" && declared != alg.String() {
+			return false
+		}
+		return true
+	})
+}
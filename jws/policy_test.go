@@ -0,0 +1,123 @@
+package jws
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyEnforcesAllowedAlgorithms(t *testing.T) {
+	secret := []byte("super-secret-key")
+	payload := []byte(`{"hello":"world"}`)
+
+	signed, err := Sign(payload, jwa.HS256, secret)
+	if !assert.NoError(t, err, `Sign should succeed`) {
+		return
+	}
+
+	_, err = Verify(signed, jwa.HS256, secret, WithAllowedAlgorithms([]jwa.SignatureAlgorithm{jwa.RS256}))
+	assert.Error(t, err, `Verify should reject HS256 when only RS256 is allowed`)
+
+	got, err := Verify(signed, jwa.HS256, secret, WithAllowedAlgorithms([]jwa.SignatureAlgorithm{jwa.HS256}))
+	if assert.NoError(t, err, `Verify should accept HS256 when it is in the allow-list`) {
+		assert.Equal(t, payload, got)
+	}
+}
+
+func TestVerifyRejectsNoneByDefault(t *testing.T) {
+	protected := NewHeaders()
+	if !assert.NoError(t, protected.Set(AlgorithmKey, jwa.NoSignature)) {
+		return
+	}
+	protectedBuf, err := json.Marshal(protected)
+	if !assert.NoError(t, err) {
+		return
+	}
+	signed := []byte(base64.RawURLEncoding.EncodeToString(protectedBuf) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{}`)) + ".")
+
+	_, err = Verify(signed, jwa.NoSignature, nil)
+	assert.Error(t, err, `Verify should reject alg="none" even without WithRejectNone`)
+}
+
+func TestVerifyRequiresKid(t *testing.T) {
+	secret := []byte("super-secret-key")
+	payload := []byte(`{"hello":"world"}`)
+
+	signed, err := Sign(payload, jwa.HS256, secret)
+	if !assert.NoError(t, err, `Sign should succeed`) {
+		return
+	}
+
+	_, err = Verify(signed, jwa.HS256, secret, WithRequireKid(true))
+	assert.Error(t, err, `Verify should reject a signature with no "kid" when WithRequireKid(true) is set`)
+}
+
+// TestVerifyWithJWKSetDefaultsToStrictAcceptor is a regression test: with no
+// acceptor supplied, VerifyWithJWKSet must not fall back to
+// DefaultJWKAcceptor, which performs no algorithm check at all and would
+// let a key published for one algorithm be used to "verify" a signature
+// claiming a different one.
+func TestVerifyWithJWKSetDefaultsToStrictAcceptor(t *testing.T) {
+	secret := []byte("super-secret-key")
+	payload := []byte(`{"hello":"world"}`)
+
+	signed, err := Sign(payload, jwa.HS256, secret)
+	if !assert.NoError(t, err, `Sign should succeed`) {
+		return
+	}
+
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err, `rsa.GenerateKey should succeed`) {
+		return
+	}
+	key, err := jwk.New(&raw.PublicKey)
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		return
+	}
+	if !assert.NoError(t, key.Set(jwk.AlgorithmKey, jwa.RS256.String())) {
+		return
+	}
+
+	set := jwk.NewSet()
+	set.Add(key)
+
+	_, err = VerifyWithJWKSet(signed, set, nil)
+	assert.Error(t, err, `VerifyWithJWKSet must reject an RS256-only key when the signature declares HS256, even with no acceptor passed`)
+}
+
+// TestDefaultJWKAcceptorRejectsEncKeys is a regression test: DefaultJWKAcceptor
+// previously accepted a key explicitly declared "use":"enc", because its
+// check only rejected unrecognized "use" values rather than anything other
+// than "sig".
+func TestDefaultJWKAcceptorRejectsEncKeys(t *testing.T) {
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err, `rsa.GenerateKey should succeed`) {
+		return
+	}
+
+	key, err := jwk.New(&raw.PublicKey)
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		return
+	}
+	if !assert.NoError(t, key.Set(jwk.KeyUsageKey, "enc")) {
+		return
+	}
+
+	assert.False(t, DefaultJWKAcceptor.Accept(key), `DefaultJWKAcceptor must reject a key declared "use":"enc"`)
+
+	if !assert.NoError(t, key.Set(jwk.KeyUsageKey, "sig")) {
+		return
+	}
+	assert.True(t, DefaultJWKAcceptor.Accept(key), `DefaultJWKAcceptor must accept a key declared "use":"sig"`)
+
+	if !assert.NoError(t, key.Set(jwk.KeyUsageKey, "")) {
+		return
+	}
+	assert.True(t, DefaultJWKAcceptor.Accept(key), `DefaultJWKAcceptor must accept a key with no declared "use"`)
+}